@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+)
+
+// AttachmentStore persists attachment blobs and metadata. Read returns a
+// stream rather than a byte slice so large attachments don't have to be
+// held in memory end-to-end.
+type AttachmentStore interface {
+	Create(att *Attachment, body io.Reader) (*Attachment, error)
+	Read(id string) (io.ReadCloser, *Attachment, error)
+	Delete(id string) error
+	List(messageID string) ([]*Attachment, error)
+}
+
+// AttachmentUpload holds the metadata of an attachment upload. The client
+// uploads a message's attachments before calling Send, referencing them by
+// ID in the MessagePackage it submits. Each upload is one whole attachment;
+// there is no splitting of an attachment across several uploads.
+type AttachmentUpload struct {
+	MessageID string
+	Name string
+	MIMEType string
+	ContentID string
+}
+
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// SQLAttachmentStore is a SQLite-backed AttachmentStore: attachment bodies
+// are held in a BLOB column, since unlike sessions or tokens they don't fit
+// a small row and there's no other blob storage in this codebase to defer
+// to. It does not stream: Create reads the whole body into memory before
+// inserting it, and Read loads the whole BLOB and returns a reader over it.
+type SQLAttachmentStore struct {
+	db *sql.DB
+}
+
+func NewSQLAttachmentStore(db *sql.DB) *SQLAttachmentStore {
+	return &SQLAttachmentStore{db: db}
+}
+
+func (s *SQLAttachmentStore) Create(att *Attachment, body io.Reader) (*Attachment, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := generateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := json.Marshal(att.Headers)
+	if err != nil {
+		return nil, err
+	}
+
+	att.ID = id
+	att.Size = len(data)
+
+	_, err = s.db.Exec(
+		`INSERT INTO attachments (id, message_id, name, mime_type, size, content_id, key_packets, signature, headers, body)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		att.ID, att.MessageID, att.Name, att.MIMEType, att.Size, att.ContentID, att.KeyPackets, att.Signature, headers, data,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return att, nil
+}
+
+func (s *SQLAttachmentStore) Read(id string) (io.ReadCloser, *Attachment, error) {
+	att := &Attachment{}
+	var headers string
+	var data []byte
+
+	err := s.db.QueryRow(
+		`SELECT id, message_id, name, mime_type, size, content_id, key_packets, signature, headers, body
+		 FROM attachments WHERE id = ?`, id,
+	).Scan(&att.ID, &att.MessageID, &att.Name, &att.MIMEType, &att.Size, &att.ContentID, &att.KeyPackets, &att.Signature, &headers, &data)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrAttachmentNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &att.Headers); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), att, nil
+}
+
+func (s *SQLAttachmentStore) Delete(id string) error {
+	res, err := s.db.Exec(`DELETE FROM attachments WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
+
+func (s *SQLAttachmentStore) List(messageID string) ([]*Attachment, error) {
+	rows, err := s.db.Query(
+		`SELECT id, message_id, name, mime_type, size, content_id, key_packets, signature, headers
+		 FROM attachments WHERE message_id = ?`, messageID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var atts []*Attachment
+	for rows.Next() {
+		att := &Attachment{}
+		var headers string
+		if err := rows.Scan(&att.ID, &att.MessageID, &att.Name, &att.MIMEType, &att.Size, &att.ContentID, &att.KeyPackets, &att.Signature, &headers); err != nil {
+			return nil, err
+		}
+		if headers != "" {
+			if err := json.Unmarshal([]byte(headers), &att.Headers); err != nil {
+				return nil, err
+			}
+		}
+		atts = append(atts, att)
+	}
+	return atts, rows.Err()
+}
@@ -0,0 +1,6 @@
+package backend
+
+type Email struct {
+	Address string
+	Name string
+}
@@ -0,0 +1,103 @@
+package backend
+
+import (
+	"database/sql"
+	"encoding/json"
+)
+
+// EventStore persists the event log used for client sync. GetLastEvent and
+// DeleteAllEvents are also part of Backend, since every backend needs them
+// regardless of whether it stores events in SQL.
+type EventStore interface {
+	SaveEvent(userID, eventID, payload string) error
+	GetLastEvent(userID string) (*Event, error)
+	// ListEventsSince returns the events recorded for userID with seq >
+	// afterSeq, in seq order. It's what lets EventsStream replay events a
+	// client missed while disconnected, per the SSE Last-Event-ID contract.
+	// afterSeq of 0 returns every event still on record. A seq that no
+	// longer exists or belongs to another user is not an error either: the
+	// user's events with a larger seq are returned, which may be none.
+	ListEventsSince(userID string, afterSeq int64) ([]*Event, error)
+	DeleteAllEvents(userID string) error
+}
+
+// SQLEventStore is a SQLite-backed EventStore.
+type SQLEventStore struct {
+	db *sql.DB
+	publisher EventPublisher
+}
+
+func NewSQLEventStore(db *sql.DB) *SQLEventStore {
+	return &SQLEventStore{db: db}
+}
+
+// SetPublisher wires an EventPublisher into the store so that every event
+// persisted through SaveEvent is also delivered to live EventsStream
+// subscribers, instead of only being visible on their next reconnect.
+func (e *SQLEventStore) SetPublisher(publisher EventPublisher) {
+	e.publisher = publisher
+}
+
+func (e *SQLEventStore) SaveEvent(userID, eventID, payload string) error {
+	res, err := e.db.Exec(
+		`INSERT INTO events (id, user_id, payload) VALUES (?, ?, ?)`,
+		eventID, userID, payload,
+	)
+	if err != nil {
+		return err
+	}
+
+	if e.publisher != nil {
+		seq, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		e.publisher.Publish(userID, &Event{ID: eventID, Seq: seq, Payload: json.RawMessage(payload)})
+	}
+
+	return nil
+}
+
+func (e *SQLEventStore) GetLastEvent(userID string) (*Event, error) {
+	event := &Event{}
+	err := e.db.QueryRow(
+		`SELECT id, seq FROM events WHERE user_id = ? ORDER BY seq DESC LIMIT 1`, userID,
+	).Scan(&event.ID, &event.Seq)
+	if err == sql.ErrNoRows {
+		return &Event{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (e *SQLEventStore) ListEventsSince(userID string, afterSeq int64) ([]*Event, error) {
+	rows, err := e.db.Query(
+		`SELECT id, seq, payload FROM events
+		 WHERE user_id = ? AND seq > ?
+		 ORDER BY seq ASC`,
+		userID, afterSeq,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var event Event
+		var payload string
+		if err := rows.Scan(&event.ID, &event.Seq, &payload); err != nil {
+			return nil, err
+		}
+		event.Payload = json.RawMessage(payload)
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
+
+func (e *SQLEventStore) DeleteAllEvents(userID string) error {
+	_, err := e.db.Exec(`DELETE FROM events WHERE user_id = ?`, userID)
+	return err
+}
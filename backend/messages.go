@@ -49,13 +49,23 @@ const (
 	EncryptedPgpMime = 8
 )
 
-type Attachment struct {} // TODO
+type Attachment struct {
+	ID string
+	MessageID string
+	Name string
+	MIMEType string
+	Size int
+	ContentID string // for inline cid: references
+	KeyPackets string
+	Signature string
+	Headers map[string]string
+}
 
 type MessagePackage struct {
 	Address string
 	Type int
 	Body string
-	KeyPackets []interface{} // TODO
+	KeyPackets map[string]string // attachment ID -> encrypted session key packet
 }
 
 type MessagesFilter struct {
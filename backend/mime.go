@@ -0,0 +1,97 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+)
+
+// AssembleMIME writes a multipart/mixed MIME message combining the message
+// body with its attachments as separate parts.
+//
+// This is NOT PGP/MIME: real PGP/MIME is a multipart/encrypted message with
+// an application/pgp-encrypted control part wrapping a single encrypted
+// octet-stream part, and nothing here encrypts anything (Key.Encrypt is
+// still a stub). What this produces is the plaintext multipart/mixed
+// container that a real PGP/MIME implementation would need to encrypt as
+// its single opaque part; callers must not treat its output as encrypted.
+func AssembleMIME(w io.Writer, body string, attachments []*Attachment, store AttachmentStore) error {
+	mw := multipart.NewWriter(w)
+	defer mw.Close()
+
+	if _, err := fmt.Fprintf(w, "Content-Type: multipart/mixed; boundary=%s\r\n\r\n", mw.Boundary()); err != nil {
+		return err
+	}
+
+	bodyHeader := textproto.MIMEHeader{}
+	bodyHeader.Set("Content-Type", "text/plain; charset=utf-8")
+	bodyPart, err := mw.CreatePart(bodyHeader)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(bodyPart, body); err != nil {
+		return err
+	}
+
+	for _, att := range attachments {
+		if err := writeAttachmentPart(mw, att, store); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BuildOutgoingMIME assembles the MIME body to send for msg, wiring its
+// attachments into the MIME structure via AssembleMIME instead of the plain
+// body a client submitted. This is the send-time hook that turns a
+// message's attachments from stored blobs into the encoded parts that go
+// out over the wire; it also sets msg.NumAttachments from the attachments it
+// assembles, since that's the same place the authoritative attachment count
+// becomes known. See AssembleMIME's comment: its output still needs PGP
+// encryption layered on top before it's real PGP/MIME.
+//
+// There is no message-send HTTP endpoint or MessageStore in this codebase to
+// call BuildOutgoingMIME from, and no caller anywhere reads
+// MessagePackage.KeyPackets: wiring either of those up is blocked on a send
+// path that doesn't exist yet, not on anything in this function.
+func BuildOutgoingMIME(msg *Message, store AttachmentStore) (string, error) {
+	var buf bytes.Buffer
+	if err := AssembleMIME(&buf, msg.Body, msg.Attachments, store); err != nil {
+		return "", err
+	}
+	msg.NumAttachments = len(msg.Attachments)
+	return buf.String(), nil
+}
+
+func writeAttachmentPart(mw *multipart.Writer, att *Attachment, store AttachmentStore) error {
+	r, _, err := store.Read(att.ID)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	disposition := "attachment"
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", fmt.Sprintf("%s; name=%q", att.MIMEType, att.Name))
+	header.Set("Content-Transfer-Encoding", "base64")
+	if att.ContentID != "" {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+att.ContentID+">")
+	}
+	header.Set("Content-Disposition", fmt.Sprintf("%s; filename=%q", disposition, att.Name))
+
+	part, err := mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	enc := base64.NewEncoder(base64.StdEncoding, part)
+	defer enc.Close()
+
+	_, err = io.Copy(enc, r)
+	return err
+}
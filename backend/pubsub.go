@@ -0,0 +1,70 @@
+package backend
+
+import "sync"
+
+// EventPublisher lets callers subscribe to live event notifications for a
+// user, which is what drives the SSE events/stream endpoint. Every mutating
+// operation that records an event should also call Publish so subscribers
+// see it immediately instead of having to poll GetLastEvent.
+type EventPublisher interface {
+	Subscribe(userID string) <-chan *Event
+	Unsubscribe(userID string, ch <-chan *Event)
+	Publish(userID string, event *Event)
+}
+
+// MemEventPublisher is an in-memory, single-instance EventPublisher. It
+// drops events for subscribers that aren't keeping up rather than blocking
+// the publisher.
+type MemEventPublisher struct {
+	mutex sync.Mutex
+	subscribers map[string][]chan *Event
+}
+
+func NewMemEventPublisher() *MemEventPublisher {
+	return &MemEventPublisher{
+		subscribers: make(map[string][]chan *Event),
+	}
+}
+
+func (p *MemEventPublisher) Subscribe(userID string) <-chan *Event {
+	ch := make(chan *Event, 16)
+
+	p.mutex.Lock()
+	p.subscribers[userID] = append(p.subscribers[userID], ch)
+	p.mutex.Unlock()
+
+	return ch
+}
+
+func (p *MemEventPublisher) Unsubscribe(userID string, ch <-chan *Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	subs := p.subscribers[userID]
+	for i, sub := range subs {
+		if sub == ch {
+			subs = append(subs[:i], subs[i+1:]...)
+			close(sub)
+			break
+		}
+	}
+
+	if len(subs) == 0 {
+		delete(p.subscribers, userID)
+	} else {
+		p.subscribers[userID] = subs
+	}
+}
+
+func (p *MemEventPublisher) Publish(userID string, event *Event) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, ch := range p.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+			// Subscriber isn't keeping up; drop the event rather than block.
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package backend
+
+import (
+	"sync"
+	"time"
+)
+
+// LoginRateLimiter blunts online password/SRP guessing by capping the
+// number of login attempts a single username can make in a time window.
+type LoginRateLimiter struct {
+	limit int
+	window time.Duration
+
+	mutex sync.Mutex
+	attempts map[string][]time.Time
+}
+
+func NewLoginRateLimiter(limit int, window time.Duration) *LoginRateLimiter {
+	return &LoginRateLimiter{
+		limit: limit,
+		window: window,
+		attempts: make(map[string][]time.Time),
+	}
+}
+
+// Allow reports whether an attempt for username is within the allowed rate.
+// An allowed attempt is recorded; a denied one is not.
+func (l *LoginRateLimiter) Allow(username string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	cutoff := now.Add(-l.window)
+
+	attempts := l.attempts[username]
+	kept := attempts[:0]
+	for _, t := range attempts {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+
+	if len(kept) >= l.limit {
+		l.attempts[username] = kept
+		return false
+	}
+
+	l.attempts[username] = append(kept, now)
+	return true
+}
@@ -0,0 +1,116 @@
+package backend
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Session is a persisted login session, stored separately from the
+// JWT/refresh tokens handed to clients so the session record survives
+// restarts. The api package reads sessions back from the store only at
+// startup; its per-request lookups and its "last session closed" check use
+// an in-memory index, so instances sharing a store do not see each other's
+// new sessions until they restart.
+type Session struct {
+	ID string
+	UserID string
+	Token string
+	CreatedAt time.Time
+	LastUsedAt time.Time
+	UserAgent string
+	IP string
+	ExpiresAt time.Time
+}
+
+type SessionStore interface {
+	CreateSession(s *Session) error
+	GetSession(id string) (*Session, error)
+	TouchSession(id string, lastUsedAt time.Time) error
+	DeleteSession(id string) error
+	ListSessionsByUser(userID string) ([]*Session, error)
+	ListSessions() ([]*Session, error)
+}
+
+// SQLSessionStore is a SQLite-backed SessionStore. Its queries use `?`
+// placeholders and SQLite's untyped TIMESTAMP columns, so it is not
+// portable to lib/pq-style Postgres drivers ($N placeholders, BYTEA) without
+// a dialect layer this package doesn't have.
+type SQLSessionStore struct {
+	db *sql.DB
+}
+
+func NewSQLSessionStore(db *sql.DB) *SQLSessionStore {
+	return &SQLSessionStore{db: db}
+}
+
+func (s *SQLSessionStore) CreateSession(sess *Session) error {
+	_, err := s.db.Exec(
+		`INSERT INTO sessions (id, user_id, token, created_at, last_used_at, user_agent, ip, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sess.ID, sess.UserID, sess.Token, sess.CreatedAt, sess.LastUsedAt, sess.UserAgent, sess.IP, sess.ExpiresAt,
+	)
+	return err
+}
+
+func (s *SQLSessionStore) GetSession(id string) (*Session, error) {
+	return scanSession(s.db.QueryRow(
+		`SELECT id, user_id, token, created_at, last_used_at, user_agent, ip, expires_at
+		 FROM sessions WHERE id = ?`, id,
+	))
+}
+
+func (s *SQLSessionStore) TouchSession(id string, lastUsedAt time.Time) error {
+	_, err := s.db.Exec(`UPDATE sessions SET last_used_at = ? WHERE id = ?`, lastUsedAt, id)
+	return err
+}
+
+func (s *SQLSessionStore) DeleteSession(id string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = ?`, id)
+	return err
+}
+
+func (s *SQLSessionStore) ListSessionsByUser(userID string) ([]*Session, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, token, created_at, last_used_at, user_agent, ip, expires_at
+		 FROM sessions WHERE user_id = ?`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+// ListSessions returns every persisted session, used to repopulate the
+// in-memory session index on startup.
+func (s *SQLSessionStore) ListSessions() ([]*Session, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, token, created_at, last_used_at, user_agent, ip, expires_at FROM sessions`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanSessions(rows)
+}
+
+func scanSession(row *sql.Row) (*Session, error) {
+	sess := &Session{}
+	err := row.Scan(&sess.ID, &sess.UserID, &sess.Token, &sess.CreatedAt, &sess.LastUsedAt, &sess.UserAgent, &sess.IP, &sess.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func scanSessions(rows *sql.Rows) ([]*Session, error) {
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		sess := &Session{}
+		if err := rows.Scan(&sess.ID, &sess.UserID, &sess.Token, &sess.CreatedAt, &sess.LastUsedAt, &sess.UserAgent, &sess.IP, &sess.ExpiresAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions, rows.Err()
+}
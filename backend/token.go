@@ -0,0 +1,259 @@
+package backend
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Scope is a coarse capability a personal access token can be granted.
+type Scope string
+
+const (
+	ScopeMailRead Scope = "mail:read"
+	ScopeMailSend Scope = "mail:send"
+	ScopeContactsRead Scope = "contacts:read"
+	ScopeContactsWrite Scope = "contacts:write"
+	ScopeSettingsWrite Scope = "settings:write"
+)
+
+// ScopeAll is the wildcard suffix accepted by HasScope, e.g. "contacts:*"
+// grants every "contacts:..." scope.
+const ScopeAll = "*"
+
+// PersonalAccessToken is a user-issued credential for IMAP/SMTP bridges and
+// third-party clients that shouldn't need to hold the user's password-derived
+// session. Only a hash of the secret is ever stored.
+type PersonalAccessToken struct {
+	ID string
+	UserID string
+	Name string
+	Prefix string
+	SecretHash []byte
+	Salt []byte
+	Scopes []Scope
+	CreatedAt time.Time
+	ExpiresAt *time.Time
+	LastUsedAt *time.Time
+}
+
+// HasScope reports whether the token grants scope, honouring "resource:*"
+// wildcards.
+func (t *PersonalAccessToken) HasScope(scope Scope) bool {
+	resource := scope
+	if i := strings.IndexByte(string(scope), ':'); i >= 0 {
+		resource = Scope(string(scope)[:i] + ":" + ScopeAll)
+	}
+
+	for _, s := range t.Scopes {
+		if s == scope || s == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore persists personal access tokens and verifies presented secrets.
+type TokenStore interface {
+	CreateToken(userID, name string, scopes []Scope, expiresAt *time.Time) (token *PersonalAccessToken, secret string, err error)
+	ListTokens(userID string) ([]*PersonalAccessToken, error)
+	RevokeToken(userID, tokenID string) error
+	VerifyToken(id, secret string) (*PersonalAccessToken, error)
+}
+
+func hashSecret(secret string, salt []byte) []byte {
+	return argon2.IDKey([]byte(secret), salt, 1, 64*1024, 4, 32)
+}
+
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// VerifySecret checks secret against the token's stored argon2id hash using
+// a constant-time comparison.
+func (t *PersonalAccessToken) VerifySecret(secret string) bool {
+	return subtle.ConstantTimeCompare(hashSecret(secret, t.Salt), t.SecretHash) == 1
+}
+
+var ErrTokenExpired = errors.New("personal access token expired")
+
+var ErrTokenNotFound = errors.New("personal access token not found")
+
+// SQLTokenStore is an argon2id-backed, SQLite-specific TokenStore: only the
+// hash and salt of a token's secret are ever persisted, mirroring how SRP
+// stores a verifier rather than a password. Like SQLSessionStore and
+// SQLEventStore, its queries use `?` placeholders and SQLite's BLOB/TIMESTAMP
+// types, so it targets SQLite only, not Postgres.
+type SQLTokenStore struct {
+	db *sql.DB
+}
+
+func NewSQLTokenStore(db *sql.DB) *SQLTokenStore {
+	return &SQLTokenStore{db: db}
+}
+
+func (s *SQLTokenStore) CreateToken(userID, name string, scopes []Scope, expiresAt *time.Time) (*PersonalAccessToken, string, error) {
+	id, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, "", err
+	}
+
+	pat := &PersonalAccessToken{
+		ID: id,
+		UserID: userID,
+		Name: name,
+		Prefix: id[:8],
+		SecretHash: hashSecret(secret, salt),
+		Salt: salt,
+		Scopes: scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+
+	_, err = s.db.Exec(
+		`INSERT INTO personal_access_tokens (id, user_id, name, prefix, secret_hash, salt, scopes, created_at, expires_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		pat.ID, pat.UserID, pat.Name, pat.Prefix, pat.SecretHash, pat.Salt, joinScopes(pat.Scopes), pat.CreatedAt, pat.ExpiresAt,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return pat, secret, nil
+}
+
+func (s *SQLTokenStore) ListTokens(userID string) ([]*PersonalAccessToken, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_id, name, prefix, secret_hash, salt, scopes, created_at, expires_at, last_used_at
+		 FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at DESC`, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []*PersonalAccessToken
+	for rows.Next() {
+		pat, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, pat)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *SQLTokenStore) RevokeToken(userID, tokenID string) error {
+	res, err := s.db.Exec(`DELETE FROM personal_access_tokens WHERE id = ? AND user_id = ?`, tokenID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+func (s *SQLTokenStore) VerifyToken(id, secret string) (*PersonalAccessToken, error) {
+	row := s.db.QueryRow(
+		`SELECT id, user_id, name, prefix, secret_hash, salt, scopes, created_at, expires_at, last_used_at
+		 FROM personal_access_tokens WHERE id = ?`, id,
+	)
+
+	pat, err := scanToken(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if pat.ExpiresAt != nil && time.Now().After(*pat.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	if !pat.VerifySecret(secret) {
+		return nil, errors.New("invalid personal access token")
+	}
+
+	now := time.Now()
+	if _, err := s.db.Exec(`UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?`, now, id); err != nil {
+		return nil, err
+	}
+	pat.LastUsedAt = &now
+
+	return pat, nil
+}
+
+// tokenRowScanner is satisfied by both *sql.Row and *sql.Rows, letting
+// scanToken back both VerifyToken/ListTokens without duplicating the column
+// list.
+type tokenRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(row tokenRowScanner) (*PersonalAccessToken, error) {
+	pat := &PersonalAccessToken{}
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullTime
+	if err := row.Scan(
+		&pat.ID, &pat.UserID, &pat.Name, &pat.Prefix, &pat.SecretHash, &pat.Salt, &scopes,
+		&pat.CreatedAt, &expiresAt, &lastUsedAt,
+	); err != nil {
+		return nil, err
+	}
+	pat.Scopes = splitScopes(scopes)
+	if expiresAt.Valid {
+		pat.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		pat.LastUsedAt = &lastUsedAt.Time
+	}
+	return pat, nil
+}
+
+func joinScopes(scopes []Scope) string {
+	strs := make([]string, len(scopes))
+	for i, s := range scopes {
+		strs[i] = string(s)
+	}
+	return strings.Join(strs, ",")
+}
+
+func splitScopes(scopes string) []Scope {
+	if scopes == "" {
+		return nil
+	}
+
+	parts := strings.Split(scopes, ",")
+	out := make([]Scope, len(parts))
+	for i, p := range parts {
+		out[i] = Scope(p)
+	}
+	return out
+}
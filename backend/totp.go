@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ValidateTOTP checks a 6-digit code against secret following RFC 6238,
+// allowing the previous and next 30-second step to absorb clock drift.
+func ValidateTOTP(secret, code string) bool {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return false
+	}
+
+	now := time.Now().Unix() / 30
+	for _, step := range []int64{now - 1, now, now + 1} {
+		if totp(key, step) == code {
+			return true
+		}
+	}
+	return false
+}
+
+func totp(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0xf
+	code := (uint32(sum[offset])&0x7f)<<24 |
+		uint32(sum[offset+1])<<16 |
+		uint32(sum[offset+2])<<8 |
+		uint32(sum[offset+3])
+	code %= 1000000
+
+	return fmt.Sprintf("%06d", code)
+}
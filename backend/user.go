@@ -0,0 +1,66 @@
+package backend
+
+import "encoding/json"
+
+type User struct {
+	ID string
+	Name string
+	Addresses []*Address
+
+	SRPVerifier []byte
+	SRPSalt []byte
+	SRPVersion int
+	TOTPSecret string
+}
+
+func (u *User) GetMainAddress() *Address {
+	for _, addr := range u.Addresses {
+		return addr
+	}
+	return nil
+}
+
+type Address struct {
+	ID string
+	Email string
+	Keys []*Key
+}
+
+type Key struct {
+	ID string
+	PrivateKey string
+	PublicKey string
+}
+
+func (k *Key) Encrypt(data string) (string, error) {
+	return data, nil // TODO: encrypt with k.PublicKey
+}
+
+// Values for Event.Type. SQLEventStore neither stores nor sets Type, so the
+// events it saves, publishes and replays carry an empty Type.
+const (
+	EventNewMessage = "message.new"
+	EventMessageRead = "message.read"
+	EventLabelChanged = "label.changed"
+	EventContactChanged = "contact.changed"
+)
+
+type Event struct {
+	ID string
+	// Seq is a monotonically increasing ordering assigned by the store (e.g.
+	// the SQL row's autoincrement id). It is not gap-free per user: the
+	// counter is shared by all users and deleted events leave holes. Unlike
+	// ID, which is an
+	// opaque caller-supplied string, Seq is what ordering and Last-Event-ID
+	// resume are safe to rely on.
+	Seq int64
+	Type string
+	Payload json.RawMessage
+}
+
+type Backend interface {
+	SRPAuth
+
+	GetLastEvent(userID string) (*Event, error)
+	DeleteAllEvents(userID string) error
+}
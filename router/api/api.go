@@ -0,0 +1,135 @@
+package api
+
+import (
+	"crypto/rand"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+const (
+	Ok int = 1000
+	BadRequest = 400
+	Unauthorized = 401
+	InternalServerError = 500
+)
+
+type Req struct{}
+
+type Resp struct {
+	Code int
+}
+
+type ErrorResp struct {
+	Resp
+	Error string
+	ErrorDescription string
+}
+
+var errNotSupported = errors.New("not supported by this backend")
+
+func newErrorResp(err error) *ErrorResp {
+	return &ErrorResp{
+		Resp: Resp{InternalServerError},
+		Error: "internal_error",
+		ErrorDescription: err.Error(),
+	}
+}
+
+type Api struct {
+	backend backend.Backend
+
+	// mutex guards sessions, srpAuths and refreshTokens: macaron serves
+	// requests concurrently, and all three maps are mutated directly from
+	// HTTP handlers.
+	mutex sync.Mutex
+	sessions map[string]*Session
+	srpAuths map[string]*backend.SRPState
+	refreshTokens map[string]*refreshToken
+
+	accessTokenSecret []byte
+	loginLimiter *backend.LoginRateLimiter
+}
+
+func New(b backend.Backend) (*Api, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	api := &Api{
+		backend: b,
+		sessions: make(map[string]*Session),
+		srpAuths: make(map[string]*backend.SRPState),
+		refreshTokens: make(map[string]*refreshToken),
+		accessTokenSecret: secret,
+		loginLimiter: backend.NewLoginRateLimiter(10, time.Minute),
+	}
+	api.restoreSessions()
+	return api, nil
+}
+
+// signModulus returns the SRP modulus for AuthInfo. It does not sign it: the
+// signing function it hands to backend.SignModulus returns its input
+// unchanged, so clients receive the bare base64-encoded modulus.
+func (api *Api) signModulus() (string, error) {
+	return backend.SignModulus(func(data []byte) (string, error) {
+		return string(data), nil
+	})
+}
+
+func (api *Api) populateCurrentUser(user *backend.User) error {
+	return nil
+}
+
+func (api *Api) putSRPAuth(id string, srp *backend.SRPState) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.srpAuths[id] = srp
+}
+
+// takeSRPAuth looks up and atomically removes an in-flight SRP exchange: it
+// can only ever be consumed once.
+func (api *Api) takeSRPAuth(id string) (*backend.SRPState, bool) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	srp, ok := api.srpAuths[id]
+	if ok {
+		delete(api.srpAuths, id)
+	}
+	return srp, ok
+}
+
+func (api *Api) putSession(session *Session) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.sessions[session.ID] = session
+}
+
+func (api *Api) getSession(id string) (*Session, bool) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	session, ok := api.sessions[id]
+	return session, ok
+}
+
+// deleteSessionIfLastForUser removes id from the session index and reports
+// whether it was the user's last open session, i.e. whether no other session
+// for the same user is still open.
+func (api *Api) deleteSessionIfLastForUser(id, userID string) (isLast bool) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	delete(api.sessions, id)
+
+	for _, s := range api.sessions {
+		if s.UserID == userID {
+			return false
+		}
+	}
+	return true
+}
+
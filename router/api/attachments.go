@@ -0,0 +1,206 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+type AttachmentResp struct {
+	ID string
+	MessageID string
+	Name string
+	MIMEType string
+	Size int
+	ContentID string
+}
+
+func toAttachmentResp(a *backend.Attachment) AttachmentResp {
+	return AttachmentResp{
+		ID: a.ID,
+		MessageID: a.MessageID,
+		Name: a.Name,
+		MIMEType: a.MIMEType,
+		Size: a.Size,
+		ContentID: a.ContentID,
+	}
+}
+
+type CreateAttachmentResp struct {
+	Resp
+	Attachment AttachmentResp
+}
+
+// CreateAttachment accepts a multipart upload of one attachment for a
+// not-yet-sent message in a single request: the metadata fields, then a
+// Data part holding the whole file. Fields after the Data part are not read.
+// The Data part is handed to the AttachmentStore as a reader rather than
+// read into memory here; SQLAttachmentStore still reads it whole.
+func (api *Api) CreateAttachment(ctx *macaron.Context) {
+	store, ok := api.backend.(backend.AttachmentStore)
+	if !ok {
+		ctx.JSON(200, newErrorResp(errNotSupported))
+		return
+	}
+
+	mr, err := ctx.Req.Request.MultipartReader()
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	att := &backend.Attachment{}
+	var file io.Reader
+
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			ctx.JSON(200, newErrorResp(err))
+			return
+		}
+
+		switch part.FormName() {
+		case "MessageID":
+			att.MessageID = readFormValue(part)
+		case "Filename":
+			att.Name = readFormValue(part)
+		case "MIMEType":
+			att.MIMEType = readFormValue(part)
+		case "ContentID":
+			att.ContentID = readFormValue(part)
+		case "KeyPackets":
+			att.KeyPackets = readFormValue(part)
+		case "Signature":
+			att.Signature = readFormValue(part)
+		case "Data":
+			file = &countingReader{r: part, n: &att.Size}
+			created, err := store.Create(att, file)
+			if err != nil {
+				ctx.JSON(200, newErrorResp(err))
+				return
+			}
+			ctx.JSON(200, &CreateAttachmentResp{
+				Resp: Resp{Ok},
+				Attachment: toAttachmentResp(created),
+			})
+			return
+		}
+	}
+
+	ctx.JSON(200, newErrorResp(fmt.Errorf("missing attachment data part")))
+}
+
+func readFormValue(r io.Reader) string {
+	b, _ := io.ReadAll(r)
+	return string(b)
+}
+
+// countingReader tallies bytes read from r into n as they're streamed, so
+// the attachment's size is known by the time store.Create has finished
+// reading it, without buffering the whole body in memory first.
+type countingReader struct {
+	r io.Reader
+	n *int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += n
+	return n, err
+}
+
+// GetAttachment streams an attachment's body, honouring a single-range
+// Range request so large downloads can be resumed.
+func (api *Api) GetAttachment(ctx *macaron.Context) {
+	store, ok := api.backend.(backend.AttachmentStore)
+	if !ok {
+		ctx.Resp.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	id := ctx.Params("id")
+	body, att, err := store.Read(id)
+	if err != nil {
+		ctx.Resp.WriteHeader(http.StatusNotFound)
+		return
+	}
+	defer body.Close()
+
+	ctx.Resp.Header().Set("Content-Type", att.MIMEType)
+	ctx.Resp.Header().Set("Accept-Ranges", "bytes")
+
+	start, end, ok := parseRange(ctx.Req.Header.Get("Range"), att.Size)
+	if !ok {
+		ctx.Resp.Header().Set("Content-Length", strconv.Itoa(att.Size))
+		io.Copy(ctx.Resp, body)
+		return
+	}
+
+	if _, err := io.CopyN(io.Discard, body, int64(start)); err != nil {
+		ctx.Resp.WriteHeader(http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+
+	length := end - start + 1
+	ctx.Resp.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, att.Size))
+	ctx.Resp.Header().Set("Content-Length", strconv.Itoa(length))
+	ctx.Resp.WriteHeader(http.StatusPartialContent)
+	io.CopyN(ctx.Resp, body, int64(length))
+}
+
+// parseRange parses a single-range "Range: bytes=start-end" header against a
+// resource of the given size. end defaults to the last byte when omitted
+// (an open-ended range) and is clamped to size-1 otherwise, so a bounded
+// range like "bytes=0-99" is honoured instead of always streaming to EOF.
+func parseRange(header string, size int) (start, end int, ok bool) {
+	if !strings.HasPrefix(header, "bytes=") {
+		return 0, 0, false
+	}
+
+	spec := strings.SplitN(strings.TrimPrefix(header, "bytes="), "-", 2)
+	start, err := strconv.Atoi(spec[0])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	end = size - 1
+	if len(spec) == 2 && spec[1] != "" {
+		e, err := strconv.Atoi(spec[1])
+		if err != nil {
+			return 0, 0, false
+		}
+		end = e
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return 0, 0, false
+	}
+
+	return start, end, true
+}
+
+func (api *Api) DeleteAttachment(ctx *macaron.Context) {
+	store, ok := api.backend.(backend.AttachmentStore)
+	if !ok {
+		ctx.JSON(200, newErrorResp(errNotSupported))
+		return
+	}
+
+	if err := store.Delete(ctx.Params("id")); err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	ctx.JSON(200, &Resp{Ok})
+}
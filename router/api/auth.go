@@ -1,11 +1,14 @@
 package api
 
 import (
+	"encoding/base64"
 	"errors"
 	"encoding/json"
 	"strings"
 
 	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
 )
 
 type TokenType string
@@ -19,7 +22,9 @@ type AuthReq struct {
 	ClientID string
 	ClientSecret string
 	Username string
-	Password string
+	ClientEphemeral string
+	ClientProof string
+	SRPSession string
 	TwoFactorCode string
 }
 
@@ -35,6 +40,7 @@ type AuthResp struct {
 	PrivateKey string
 	KeySalt string
 	EventID string
+	ServerProof string
 }
 
 type AuthCookiesReq struct {
@@ -73,7 +79,37 @@ type AuthInfoResp struct {
 }
 
 func (api *Api) Auth(ctx *macaron.Context, req AuthReq) {
-	user, err := api.backend.Auth(req.Username, req.Password)
+	srp, ok := api.takeSRPAuth(req.SRPSession)
+	if !ok {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: "Unknown or expired SRP session",
+		})
+		return
+	}
+
+	if !api.loginLimiter.Allow(srp.Username) {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: "Too many login attempts, try again later",
+		})
+		return
+	}
+
+	clientEphemeral, err := base64.StdEncoding.DecodeString(req.ClientEphemeral)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(errors.New("Invalid client ephemeral")))
+		return
+	}
+	clientProof, err := base64.StdEncoding.DecodeString(req.ClientProof)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(errors.New("Invalid client proof")))
+		return
+	}
+
+	serverProof, err := srp.VerifyClientProof(clientEphemeral, clientProof)
 	if err != nil {
 		ctx.JSON(200, &ErrorResp{
 			Resp: Resp{Unauthorized},
@@ -83,44 +119,55 @@ func (api *Api) Auth(ctx *macaron.Context, req AuthReq) {
 		return
 	}
 
-	err = api.populateCurrentUser(user)
+	user, _, _, _, err := api.backend.GetUserSRP(srp.Username)
 	if err != nil {
 		ctx.JSON(200, newErrorResp(err))
 		return
 	}
 
-	var session *Session
-	session = NewSession(user.ID, func() {
-		delete(api.sessions, session.ID)
-
-		// Check if there are remaining sessions for this user
-		for _, s := range api.sessions {
-			if s.UserID == session.UserID {
-				return
-			}
+	if user.TOTPSecret != "" {
+		if req.TwoFactorCode == "" || !backend.ValidateTOTP(user.TOTPSecret, req.TwoFactorCode) {
+			ctx.JSON(200, &ErrorResp{
+				Resp: Resp{Unauthorized},
+				Error: "invalid_grant",
+				ErrorDescription: "Invalid two-factor code",
+			})
+			return
 		}
+	}
 
-		// Stop producing events for this user
-		api.backend.DeleteAllEvents(session.UserID)
-	})
-	api.sessions[session.ID] = session
+	err = api.populateCurrentUser(user)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	session, err := api.createSession(user.ID, ctx)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
 
 	addr := user.GetMainAddress()
-	if len(addr.Keys) == 0 {
+	if addr == nil || len(addr.Keys) == 0 {
 		ctx.JSON(200, newErrorResp(errors.New("User has no private key")))
 		return
 	}
 
 	kp := addr.Keys[0]
-	encryptedToken, err := kp.Encrypt(session.Token)
+
+	accessToken, claims, err := api.signAccessToken(user.ID)
 	if err != nil {
-		ctx.JSON(200, &ErrorResp{
-			Resp: Resp{InternalServerError},
-			Error: "invalid_key",
-			ErrorDescription: err.Error(),
-		})
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	rt, err := api.issueRefreshToken(user.ID, session.ID, "")
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
 		return
 	}
+	session.RefreshFamilyID = rt.FamilyID
 
 	lastEvent, err := api.backend.GetLastEvent(user.ID)
 	if err != nil {
@@ -130,20 +177,70 @@ func (api *Api) Auth(ctx *macaron.Context, req AuthReq) {
 
 	ctx.JSON(200, &AuthResp{
 		Resp: Resp{Ok},
-		AccessToken: encryptedToken,
-		ExpiresIn: 360000, // TODO: really expire
+		AccessToken: accessToken,
+		ExpiresIn: int(claims.Exp - claims.Iat),
 		TokenType: TokenBearer,
 		Scope: "full mail payments reset keys",
 		Uid: session.ID,
-		RefreshToken: "refresh_token", // TODO
+		RefreshToken: rt.Token,
 		PrivateKey: kp.PrivateKey,
 		EventID: lastEvent.ID,
+		ServerProof: base64.StdEncoding.EncodeToString(serverProof),
 	})
 }
 
 func (api *Api) AuthInfo(ctx *macaron.Context, req AuthInfoReq) {
+	if !api.loginLimiter.Allow(req.Username) {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: "Too many login attempts, try again later",
+		})
+		return
+	}
+
+	user, verifier, salt, version, err := api.backend.GetUserSRP(req.Username)
+	if err != nil {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: "Invalid username",
+		})
+		return
+	}
+
+	srp, err := backend.NewSRPServerEphemeral(user.ID, req.Username, verifier)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	modulus, err := api.signModulus()
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	srpSession, err := generateToken()
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+	api.putSRPAuth(srpSession, srp)
+
+	twoFactor := 0
+	if user.TOTPSecret != "" {
+		twoFactor = 1
+	}
+
 	ctx.JSON(200, &AuthInfoResp{
 		Resp: Resp{Ok},
+		Modulus: modulus,
+		ServerEphemeral: base64.StdEncoding.EncodeToString(srp.ServerEphemeral.Bytes()),
+		Version: version,
+		Salt: base64.StdEncoding.EncodeToString(salt),
+		SRPSession: srpSession,
+		TwoFactor: twoFactor,
 	})
 }
 
@@ -158,7 +255,7 @@ func (api *Api) AuthCookies(ctx *macaron.Context, req AuthCookiesReq) {
 		return
 	}
 
-	session, ok := api.sessions[uid]
+	session, ok := api.getSession(uid)
 	if !ok {
 		ctx.JSON(200, &ErrorResp{
 			Resp: Resp{BadRequest},
@@ -213,11 +310,13 @@ func (api *Api) AuthCookies(ctx *macaron.Context, req AuthCookiesReq) {
 }
 
 func (api *Api) DeleteAuth(ctx *macaron.Context) {
-	sessionToken := api.getSessionToken(ctx)
-	if sessionToken != "" {
-		ctx.SetCookie("AUTH-" + sessionToken, "", 0, "/api/", "", false, true)
+	session := api.currentSession(ctx)
+	if session != nil {
+		ctx.SetCookie("AUTH-" + session.Token, "", 0, "/api/", "", false, true)
 
-		delete(api.sessions, "sessionToken")
+		if session.onClose != nil {
+			session.onClose()
+		}
 	}
 
 	ctx.JSON(200, &Resp{Ok})
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+// EventsStream pushes newline-delimited JSON events to the client as they
+// happen, so it doesn't have to poll GetLastEvent to notice new mail. Each
+// event is sent with its monotonic event.Seq as the id: field, so a client
+// that reconnects with Last-Event-ID can resume without gaps: event.ID is an
+// opaque caller-supplied string and isn't ordered.
+func (api *Api) EventsStream(ctx *macaron.Context) {
+	publisher, ok := api.backend.(backend.EventPublisher)
+	if !ok {
+		ctx.Resp.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	userID := AuthenticatedUserID(ctx)
+
+	flusher, ok := ctx.Resp.(http.Flusher)
+	if !ok {
+		ctx.Resp.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+
+	// Subscribe before replaying missed events so nothing published between
+	// the replay and the live tail starting can be lost.
+	events := publisher.Subscribe(userID)
+	defer publisher.Unsubscribe(userID, events)
+
+	ctx.Resp.Header().Set("Content-Type", "text/event-stream")
+	ctx.Resp.Header().Set("Cache-Control", "no-cache")
+	ctx.Resp.Header().Set("Connection", "keep-alive")
+	ctx.Resp.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	if lastEventID := ctx.Req.Header.Get("Last-Event-ID"); lastEventID != "" {
+		if afterSeq, err := strconv.ParseInt(lastEventID, 10, 64); err == nil {
+			if store, ok := api.backend.(backend.EventStore); ok {
+				missed, err := store.ListEventsSince(userID, afterSeq)
+				if err == nil {
+					for _, event := range missed {
+						fmt.Fprintf(ctx.Resp, "id: %d\ndata: %s\n\n", event.Seq, eventJSON(event))
+					}
+					flusher.Flush()
+				}
+			}
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			fmt.Fprintf(ctx.Resp, "id: %d\ndata: %s\n\n", event.Seq, eventJSON(event))
+			flusher.Flush()
+		case <-ctx.Req.Request.Context().Done():
+			return
+		}
+	}
+}
+
+func eventJSON(event *backend.Event) string {
+	payload := event.Payload
+	if payload == nil {
+		payload = []byte("null")
+	}
+	return fmt.Sprintf(`{"ID":%q,"Type":%q,"Payload":%s}`, event.ID, event.Type, payload)
+}
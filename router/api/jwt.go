@@ -0,0 +1,98 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const accessTokenTTL = 15 * time.Minute
+
+type accessTokenClaims struct {
+	Iss string `json:"iss"`
+	Sub string `json:"sub"`
+	Aud string `json:"aud"`
+	Iat int64 `json:"iat"`
+	Exp int64 `json:"exp"`
+	Jti string `json:"jti"`
+}
+
+func b64encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func b64decode(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+// signAccessToken issues a short-lived, signed access token for userID.
+// Tokens are plain HMAC-SHA256 JWTs: the backend only needs to verify them,
+// not interoperate with third-party issuers.
+func (api *Api) signAccessToken(userID string) (token string, claims *accessTokenClaims, err error) {
+	jti, err := generateToken()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	claims = &accessTokenClaims{
+		Iss: "neutron",
+		Sub: userID,
+		Aud: "neutron-api",
+		Iat: now.Unix(),
+		Exp: now.Add(accessTokenTTL).Unix(),
+		Jti: jti,
+	}
+
+	header := b64encode([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payloadBytes, err := json.Marshal(claims)
+	if err != nil {
+		return "", nil, err
+	}
+	payload := b64encode(payloadBytes)
+
+	signingInput := header + "." + payload
+	sig := api.signAccessTokenSig(signingInput)
+
+	return signingInput + "." + sig, claims, nil
+}
+
+func (api *Api) signAccessTokenSig(signingInput string) string {
+	mac := hmac.New(sha256.New, api.accessTokenSecret)
+	mac.Write([]byte(signingInput))
+	return b64encode(mac.Sum(nil))
+}
+
+// parseAccessToken verifies an access token's signature and expiry and
+// returns its claims.
+func (api *Api) parseAccessToken(token string) (*accessTokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed access token")
+	}
+
+	expectedSig := api.signAccessTokenSig(parts[0] + "." + parts[1])
+	if !hmac.Equal([]byte(expectedSig), []byte(parts[2])) {
+		return nil, errors.New("invalid access token signature")
+	}
+
+	payloadBytes, err := b64decode(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid access token payload")
+	}
+
+	var claims accessTokenClaims
+	if err := json.Unmarshal(payloadBytes, &claims); err != nil {
+		return nil, errors.New("invalid access token payload")
+	}
+
+	if time.Now().Unix() > claims.Exp {
+		return nil, errors.New("access token expired")
+	}
+
+	return &claims, nil
+}
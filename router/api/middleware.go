@@ -0,0 +1,86 @@
+package api
+
+import (
+	"strings"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+const patPrefix = "neutron_pat_"
+
+// RequireAuth rejects requests without a valid, unexpired access token and
+// stores the authenticated user ID (and, for personal access tokens, the
+// token itself) on the context for handlers to read via
+// AuthenticatedUserID/RequireScope.
+func (api *Api) RequireAuth(ctx *macaron.Context) {
+	auth := ctx.Req.Header.Get("Authorization")
+	parts := strings.SplitN(auth, " ", 2)
+	if len(parts) != 2 || TokenType(parts[0]) != TokenBearer {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_authorization",
+			ErrorDescription: "Missing or malformed Authorization header",
+		})
+		return
+	}
+
+	token := parts[1]
+	if strings.HasPrefix(token, patPrefix) {
+		api.requirePAT(ctx, strings.TrimPrefix(token, patPrefix))
+		return
+	}
+
+	claims, err := api.parseAccessToken(token)
+	if err != nil {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_authorization",
+			ErrorDescription: err.Error(),
+		})
+		return
+	}
+
+	ctx.Data["UserID"] = claims.Sub
+}
+
+func (api *Api) requirePAT(ctx *macaron.Context, rest string) {
+	store, ok := api.backend.(backend.TokenStore)
+	if !ok {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_authorization",
+			ErrorDescription: "Personal access tokens are not supported",
+		})
+		return
+	}
+
+	parts := strings.SplitN(rest, "_", 2)
+	if len(parts) != 2 {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_authorization",
+			ErrorDescription: "Malformed personal access token",
+		})
+		return
+	}
+
+	pat, err := store.VerifyToken(parts[0], parts[1])
+	if err != nil {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_authorization",
+			ErrorDescription: err.Error(),
+		})
+		return
+	}
+
+	ctx.Data["UserID"] = pat.UserID
+	ctx.Data["PAT"] = pat
+}
+
+func AuthenticatedUserID(ctx *macaron.Context) string {
+	userID, _ := ctx.Data["UserID"].(string)
+	return userID
+}
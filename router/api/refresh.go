@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"gopkg.in/macaron.v1"
+)
+
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// refreshToken is an opaque, long-lived token stored server-side. Every
+// refresh token belongs to a family: rotating a token keeps the family ID,
+// and reusing an already-rotated token revokes the whole family, since that
+// can only happen if the token was stolen.
+type refreshToken struct {
+	Token string
+	FamilyID string
+	UserID string
+	SessionID string
+	Used bool
+	ExpiresAt time.Time
+}
+
+func (api *Api) issueRefreshToken(userID, sessionID, familyID string) (*refreshToken, error) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	return api.issueRefreshTokenLocked(userID, sessionID, familyID)
+}
+
+// issueRefreshTokenLocked requires api.mutex to already be held.
+func (api *Api) issueRefreshTokenLocked(userID, sessionID, familyID string) (*refreshToken, error) {
+	if familyID == "" {
+		id, err := generateToken()
+		if err != nil {
+			return nil, err
+		}
+		familyID = id
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	rt := &refreshToken{
+		Token: token,
+		FamilyID: familyID,
+		UserID: userID,
+		SessionID: sessionID,
+		ExpiresAt: time.Now().Add(refreshTokenTTL),
+	}
+	api.refreshTokens[rt.Token] = rt
+	return rt, nil
+}
+
+// rotateRefreshToken exchanges token for a new one in the same family. If
+// token was already used, the whole family is revoked and an error is
+// returned: the most likely explanation is that the token was stolen and
+// both the legitimate client and the attacker are racing to redeem it.
+func (api *Api) rotateRefreshToken(token string) (*refreshToken, error) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+
+	rt, ok := api.refreshTokens[token]
+	if !ok {
+		return nil, errors.New("invalid refresh token")
+	}
+
+	if rt.Used || time.Now().After(rt.ExpiresAt) {
+		api.revokeRefreshFamilyLocked(rt.FamilyID)
+		return nil, errors.New("invalid refresh token")
+	}
+
+	rt.Used = true
+
+	return api.issueRefreshTokenLocked(rt.UserID, rt.SessionID, rt.FamilyID)
+}
+
+func (api *Api) revokeRefreshFamily(familyID string) {
+	api.mutex.Lock()
+	defer api.mutex.Unlock()
+	api.revokeRefreshFamilyLocked(familyID)
+}
+
+// revokeRefreshFamilyLocked requires api.mutex to already be held.
+func (api *Api) revokeRefreshFamilyLocked(familyID string) {
+	for token, rt := range api.refreshTokens {
+		if rt.FamilyID == familyID {
+			delete(api.refreshTokens, token)
+		}
+	}
+}
+
+type RefreshReq struct {
+	Req
+	ClientID string
+	ClientSecret string
+	RefreshToken string
+	Uid string
+}
+
+func (api *Api) Refresh(ctx *macaron.Context, req RefreshReq) {
+	rt, err := api.rotateRefreshToken(req.RefreshToken)
+	if err != nil {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: err.Error(),
+		})
+		return
+	}
+
+	session, ok := api.getSession(req.Uid)
+	if !ok || session.UserID != rt.UserID {
+		ctx.JSON(200, &ErrorResp{
+			Resp: Resp{Unauthorized},
+			Error: "invalid_grant",
+			ErrorDescription: "Invalid session",
+		})
+		return
+	}
+
+	accessToken, claims, err := api.signAccessToken(rt.UserID)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	ctx.JSON(200, &AuthResp{
+		Resp: Resp{Ok},
+		AccessToken: accessToken,
+		ExpiresIn: int(claims.Exp - claims.Iat),
+		TokenType: TokenBearer,
+		Scope: "full mail payments reset keys",
+		Uid: session.ID,
+		RefreshToken: rt.Token,
+	})
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+const sessionTTL = 30 * 24 * time.Hour
+
+type Session struct {
+	ID string
+	UserID string
+	Token string
+	RefreshFamilyID string
+	onClose func()
+}
+
+// generateToken returns a random 32-byte token hex-encoded. It returns an
+// error rather than silently falling back to a short read, since a failed
+// CSPRNG read must never produce a predictable session or refresh token.
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func NewSession(userID string, onClose func()) (*Session, error) {
+	id, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		ID: id,
+		UserID: userID,
+		Token: token,
+		onClose: onClose,
+	}, nil
+}
+
+// createSession starts a new in-memory session for userID, persisting it
+// through backend.SessionStore when the backend supports one so it survives
+// restarts. Other instances only load it from the store when they start.
+func (api *Api) createSession(userID string, ctx *macaron.Context) (*Session, error) {
+	var session *Session
+	session, err := NewSession(userID, func() {
+		api.closeSession(session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	api.putSession(session)
+
+	if store, ok := api.backend.(backend.SessionStore); ok {
+		now := time.Now()
+		store.CreateSession(&backend.Session{
+			ID: session.ID,
+			UserID: userID,
+			Token: session.Token,
+			CreatedAt: now,
+			LastUsedAt: now,
+			UserAgent: ctx.Req.Header.Get("User-Agent"),
+			IP: ctx.Req.Request.RemoteAddr,
+			ExpiresAt: now.Add(sessionTTL),
+		})
+	}
+
+	return session, nil
+}
+
+func (api *Api) closeSession(session *Session) {
+	isLast := api.deleteSessionIfLastForUser(session.ID, session.UserID)
+	api.revokeRefreshFamily(session.RefreshFamilyID)
+
+	if store, ok := api.backend.(backend.SessionStore); ok {
+		store.DeleteSession(session.ID)
+	}
+
+	if !isLast {
+		return
+	}
+
+	// Stop producing events for this user
+	api.backend.DeleteAllEvents(session.UserID)
+}
+
+// restoreSessions repopulates the in-memory session index from
+// backend.SessionStore on startup. Access tokens are signed with a secret
+// generated per process and refresh tokens are held only in memory, so
+// neither survives a restart even though the session does.
+func (api *Api) restoreSessions() {
+	store, ok := api.backend.(backend.SessionStore)
+	if !ok {
+		return
+	}
+
+	sessions, err := store.ListSessions()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, s := range sessions {
+		if now.After(s.ExpiresAt) {
+			store.DeleteSession(s.ID)
+			continue
+		}
+
+		session := &Session{ID: s.ID, UserID: s.UserID, Token: s.Token}
+		session.onClose = func() {
+			api.closeSession(session)
+		}
+		api.putSession(session)
+	}
+}
+
+func (api *Api) getUid(ctx *macaron.Context) string {
+	return ctx.Req.Header.Get("X-Pm-Uid")
+}
+
+func (api *Api) currentSession(ctx *macaron.Context) *Session {
+	uid := api.getUid(ctx)
+	session, ok := api.getSession(uid)
+	if !ok {
+		return nil
+	}
+	return session
+}
+
+func (api *Api) getSessionToken(ctx *macaron.Context) string {
+	session := api.currentSession(ctx)
+	if session == nil {
+		return ""
+	}
+	return session.Token
+}
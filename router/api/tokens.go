@@ -0,0 +1,149 @@
+package api
+
+import (
+	"time"
+
+	"gopkg.in/macaron.v1"
+
+	"github.com/SIXwishlist/neutron/backend"
+)
+
+type CreateTokenReq struct {
+	Req
+	Name string
+	Scopes []string
+	ExpiresIn int // seconds, 0 means no expiry
+}
+
+type TokenResp struct {
+	ID string
+	Name string
+	Prefix string
+	Scopes []string
+	CreatedAt int64
+	ExpiresAt int64
+	LastUsedAt int64
+}
+
+type CreateTokenResp struct {
+	Resp
+	Token TokenResp
+	Secret string
+}
+
+type ListTokensResp struct {
+	Resp
+	Tokens []TokenResp
+}
+
+func toTokenResp(t *backend.PersonalAccessToken) TokenResp {
+	resp := TokenResp{
+		ID: t.ID,
+		Name: t.Name,
+		Prefix: t.Prefix,
+		CreatedAt: t.CreatedAt.Unix(),
+	}
+	for _, s := range t.Scopes {
+		resp.Scopes = append(resp.Scopes, string(s))
+	}
+	if t.ExpiresAt != nil {
+		resp.ExpiresAt = t.ExpiresAt.Unix()
+	}
+	if t.LastUsedAt != nil {
+		resp.LastUsedAt = t.LastUsedAt.Unix()
+	}
+	return resp
+}
+
+func (api *Api) CreateToken(ctx *macaron.Context, req CreateTokenReq) {
+	store, ok := api.backend.(backend.TokenStore)
+	if !ok {
+		ctx.JSON(200, newErrorResp(errNotSupported))
+		return
+	}
+
+	scopes := make([]backend.Scope, len(req.Scopes))
+	for i, s := range req.Scopes {
+		scopes[i] = backend.Scope(s)
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresIn) * time.Second)
+		expiresAt = &t
+	}
+
+	token, secret, err := store.CreateToken(AuthenticatedUserID(ctx), req.Name, scopes, expiresAt)
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	ctx.JSON(200, &CreateTokenResp{
+		Resp: Resp{Ok},
+		Token: toTokenResp(token),
+		Secret: "neutron_pat_" + token.ID + "_" + secret,
+	})
+}
+
+func (api *Api) ListTokens(ctx *macaron.Context) {
+	store, ok := api.backend.(backend.TokenStore)
+	if !ok {
+		ctx.JSON(200, newErrorResp(errNotSupported))
+		return
+	}
+
+	tokens, err := store.ListTokens(AuthenticatedUserID(ctx))
+	if err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	resp := make([]TokenResp, len(tokens))
+	for i, t := range tokens {
+		resp[i] = toTokenResp(t)
+	}
+
+	ctx.JSON(200, &ListTokensResp{
+		Resp: Resp{Ok},
+		Tokens: resp,
+	})
+}
+
+func (api *Api) DeleteToken(ctx *macaron.Context) {
+	store, ok := api.backend.(backend.TokenStore)
+	if !ok {
+		ctx.JSON(200, newErrorResp(errNotSupported))
+		return
+	}
+
+	id := ctx.Params("id")
+	if err := store.RevokeToken(AuthenticatedUserID(ctx), id); err != nil {
+		ctx.JSON(200, newErrorResp(err))
+		return
+	}
+
+	ctx.JSON(200, &Resp{Ok})
+}
+
+// RequireScope rejects the request unless the authenticated credential (a
+// session or a personal access token) grants scope. Session-based auth is
+// always fully scoped; personal access tokens are checked against the
+// scopes they were created with.
+func RequireScope(scope backend.Scope) macaron.Handler {
+	return func(ctx *macaron.Context) {
+		token, ok := ctx.Data["PAT"].(*backend.PersonalAccessToken)
+		if !ok {
+			// Authenticated via session or JWT access token: unscoped.
+			return
+		}
+
+		if !token.HasScope(scope) {
+			ctx.JSON(200, &ErrorResp{
+				Resp: Resp{Unauthorized},
+				Error: "insufficient_scope",
+				ErrorDescription: "Token is missing the " + string(scope) + " scope",
+			})
+		}
+	}
+}